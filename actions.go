@@ -0,0 +1,246 @@
+package hpcloud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+/*
+  doAction POSTs a single-key action envelope, e.g. {"resize":
+  {"flavorRef": 102}}, to servers/{id}/action and returns the raw
+  response body. Most actions return no body at all, in which case
+  the caller ignores it.
+*/
+func (a Access) doAction(serverID, action string, payload interface{}) ([]byte, error) {
+	b, err := json.Marshal(map[string]interface{}{action: payload})
+	if err != nil {
+		return nil, err
+	}
+	return a.baseComputeRequest(
+		fmt.Sprintf("servers/%s/action", serverID), "POST",
+		bytes.NewReader(b),
+	)
+}
+
+/*
+  baseComputeRequestHeader is identical to baseComputeRequest except
+  that it also returns the response headers, for the one action
+  (CreateImageFromServer) that needs to read the Location header
+  back. It goes through authenticatedRequestHeader so it gets the
+  same token refresh / 401-retry behaviour as baseComputeRequest.
+*/
+func (a Access) baseComputeRequestHeader(url, method string, b *bytes.Reader) (http.Header, []byte, error) {
+	path := fmt.Sprintf("%s%s/%s", COMPUTE_URL, a.TenantID, url)
+	return a.authenticatedRequestHeader(context.Background(), path, method, b)
+}
+
+/*
+  ResizeServer requests a resize of the server with `serverID` to
+  the given flavor. The server transitions through RESIZE and
+  VERIFY_RESIZE; call ConfirmResize or RevertResize once it reaches
+  VERIFY_RESIZE.
+
+  This function implements the interface described in:-
+  * https://docs.hpcloud.com/api/compute/
+  * Section 4.4.7.3 Resize Server
+*/
+func (a Access) ResizeServer(serverID string, flavor Flavor) error {
+	_, err := a.doAction(serverID, "resize", map[string]interface{}{
+		"flavorRef": flavor,
+	})
+	return err
+}
+
+/*
+  ConfirmResize confirms a pending resize of the server with
+  `serverID`, releasing the old server resources.
+
+  This function implements the interface described in:-
+  * https://docs.hpcloud.com/api/compute/
+  * Section 4.4.7.4 Confirm Resized Server
+*/
+func (a Access) ConfirmResize(serverID string) error {
+	_, err := a.doAction(serverID, "confirmResize", nil)
+	return err
+}
+
+/*
+  RevertResize reverts a pending resize of the server with
+  `serverID`, restoring the old server.
+
+  This function implements the interface described in:-
+  * https://docs.hpcloud.com/api/compute/
+  * Section 4.4.7.5 Revert Resized Server
+*/
+func (a Access) RevertResize(serverID string) error {
+	_, err := a.doAction(serverID, "revertResize", nil)
+	return err
+}
+
+/*
+  RebuildOpts holds the settings accepted by RebuildServer. ImageRef
+  is required; the rest are optional and are omitted from the
+  request when left at their zero value.
+*/
+type RebuildOpts struct {
+	ImageRef    ServerImage
+	Name        string
+	AdminPass   string
+	Metadata    map[string]string
+	Personality string
+}
+
+/*
+  RebuildServer reinitializes the server with `serverID` to the
+  image and settings in opts, keeping its id, IP addresses and name
+  (unless overridden).
+
+  This function implements the interface described in:-
+  * https://docs.hpcloud.com/api/compute/
+  * Section 4.4.7.2 Rebuild Server
+*/
+func (a Access) RebuildServer(serverID string, opts RebuildOpts) (*ServerResponse, error) {
+	payload := map[string]interface{}{
+		"imageRef": opts.ImageRef,
+	}
+	if opts.Name != "" {
+		payload["name"] = opts.Name
+	}
+	if opts.AdminPass != "" {
+		payload["adminPass"] = opts.AdminPass
+	}
+	if len(opts.Metadata) > 0 {
+		payload["metadata"] = opts.Metadata
+	}
+	if opts.Personality != "" {
+		payload["personality"] = opts.Personality
+	}
+
+	body, err := a.doAction(serverID, "rebuild", payload)
+	if err != nil {
+		return nil, err
+	}
+	sr := &ServerResponse{}
+	if err := json.Unmarshal(body, sr); err != nil {
+		return nil, err
+	}
+	return sr, nil
+}
+
+/*
+  CreateImageFromServer snapshots the server with `serverID` into a
+  new image called `name`, tagging it with the given metadata, and
+  returns the id of the newly created image. The id is recovered
+  from the Location header of the action response rather than the
+  (empty) body.
+
+  This function implements the interface described in:-
+  * https://docs.hpcloud.com/api/compute/
+  * Section 4.4.7.6 Create Image
+*/
+func (a Access) CreateImageFromServer(serverID, name string, metadata map[string]string) (string, error) {
+	payload := map[string]interface{}{"name": name}
+	if len(metadata) > 0 {
+		payload["metadata"] = metadata
+	}
+	b, err := json.Marshal(map[string]interface{}{"createImage": payload})
+	if err != nil {
+		return "", err
+	}
+
+	header, _, err := a.baseComputeRequestHeader(
+		fmt.Sprintf("servers/%s/action", serverID), "POST",
+		bytes.NewReader(b),
+	)
+	if err != nil {
+		return "", err
+	}
+	loc := header.Get("Location")
+	if loc == "" {
+		return "", errors.New("hpcloud: createImage response had no Location header")
+	}
+	parts := strings.Split(loc, "/")
+	return parts[len(parts)-1], nil
+}
+
+/*
+  StartServer powers on a server with `serverID` that was previously
+  stopped with StopServer.
+
+  This function implements the interface described in:-
+  * https://docs.hpcloud.com/api/compute/
+  * Section 4.4.7.7 Start Server
+*/
+func (a Access) StartServer(serverID string) error {
+	_, err := a.doAction(serverID, "os-start", nil)
+	return err
+}
+
+/*
+  StopServer gracefully powers off the server with `serverID`
+  without deleting it.
+
+  This function implements the interface described in:-
+  * https://docs.hpcloud.com/api/compute/
+  * Section 4.4.7.8 Stop Server
+*/
+func (a Access) StopServer(serverID string) error {
+	_, err := a.doAction(serverID, "os-stop", nil)
+	return err
+}
+
+/*
+  RescueServer reboots the server with `serverID` into rescue mode,
+  returning the new, temporary admin password needed to log in to
+  it in that mode.
+
+  This function implements the interface described in:-
+  * https://docs.hpcloud.com/api/compute/
+  * Section 4.4.7.9 Rescue Server
+*/
+func (a Access) RescueServer(serverID string) (string, error) {
+	body, err := a.doAction(serverID, "rescue", nil)
+	if err != nil {
+		return "", err
+	}
+	var resp struct {
+		AdminPass string `json:"adminPass"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", err
+	}
+	return resp.AdminPass, nil
+}
+
+/*
+  UnrescueServer takes the server with `serverID` back out of rescue
+  mode.
+
+  This function implements the interface described in:-
+  * https://docs.hpcloud.com/api/compute/
+  * Section 4.4.7.10 Unrescue Server
+*/
+func (a Access) UnrescueServer(serverID string) error {
+	_, err := a.doAction(serverID, "unrescue", nil)
+	return err
+}
+
+/*
+  ChangeAdminPassword sets the admin/root password of the server
+  with `serverID` to `pass`.
+
+  This function implements the interface described in:-
+  * https://docs.hpcloud.com/api/compute/
+  * Section 4.4.7.11 Change Administrative Password
+*/
+func (a Access) ChangeAdminPassword(serverID, pass string) error {
+	_, err := a.doAction(serverID, "changePassword", map[string]interface{}{
+		"adminPass": pass,
+	})
+	return err
+}
@@ -0,0 +1,411 @@
+package hpcloud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+  Authenticator abstracts over the different ways a caller can
+  prove their identity to Keystone. authenticatedRequest calls Token
+  before every request, and again, once, if the request comes back
+  401, so any Authenticator must be safe to call repeatedly and
+  cache/refresh its own token as it sees fit.
+*/
+type Authenticator interface {
+	/* Token returns a bearer token valid for use against the
+	   Compute API right now, re-issuing one from Keystone if the
+	   previous one has expired or was never fetched. */
+	Token(ctx context.Context) (string, error)
+	/* Invalidate discards any cached token, forcing the next call
+	   to Token to re-authenticate against Keystone. */
+	Invalidate()
+}
+
+/*
+  keystoneToken is the subset of a Keystone v3 token response this
+  package cares about: the bearer token itself (from the
+  X-Subject-Token header) and when it expires.
+*/
+type keystoneToken struct {
+	value   string
+	expires time.Time
+}
+
+func (t keystoneToken) valid() bool {
+	return t.value != "" && time.Now().Before(t.expires)
+}
+
+/*
+  projectScope builds the "scope" object of a /v3/auth/tokens
+  request for project scoping: by id if one is given, otherwise by
+  name (optionally disambiguated by domain). It returns nil when
+  neither id nor name is set, leaving the request unscoped.
+*/
+func projectScope(id, name, domain string) map[string]interface{} {
+	if id == "" && name == "" {
+		return nil
+	}
+	project := map[string]interface{}{}
+	if id != "" {
+		project["id"] = id
+	} else {
+		project["name"] = name
+		if domain != "" {
+			project["domain"] = map[string]interface{}{"name": domain}
+		}
+	}
+	return map[string]interface{}{"project": project}
+}
+
+/*
+  issueToken POSTs an identity (and, if given, scope) payload to
+  /v3/auth/tokens and returns the resulting token, reading the
+  bearer value off the X-Subject-Token header and the expiry out of
+  the JSON body. A nil scope yields an unscoped token.
+*/
+func issueToken(ctx context.Context, identityURL string, identity, scope map[string]interface{}) (keystoneToken, error) {
+	auth := map[string]interface{}{"identity": identity}
+	if scope != nil {
+		auth["scope"] = scope
+	}
+	payload, err := json.Marshal(map[string]interface{}{"auth": auth})
+	if err != nil {
+		return keystoneToken{}, err
+	}
+
+	req, err := http.NewRequest(
+		"POST", strings.TrimRight(identityURL, "/")+"/v3/auth/tokens",
+		bytes.NewReader(payload),
+	)
+	if err != nil {
+		return keystoneToken{}, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return keystoneToken{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return keystoneToken{}, fmt.Errorf("hpcloud: identity auth failed with status %s", resp.Status)
+	}
+
+	subjectToken := resp.Header.Get("X-Subject-Token")
+	if subjectToken == "" {
+		return keystoneToken{}, errors.New("hpcloud: identity response had no X-Subject-Token header")
+	}
+
+	var body struct {
+		Token struct {
+			ExpiresAt time.Time `json:"expires_at"`
+		} `json:"token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return keystoneToken{}, err
+	}
+
+	return keystoneToken{value: subjectToken, expires: body.Token.ExpiresAt}, nil
+}
+
+/*
+  PasswordAuthenticator authenticates with a Keystone username and
+  password. DomainName only disambiguates Username within Keystone
+  (identity.password.user.domain); it does not scope the resulting
+  token. Set ProjectID (or ProjectName, optionally with
+  ProjectDomainName) to get a project-scoped token, which is what
+  the tenant-scoped Compute URL requires — leaving both blank yields
+  an unscoped token that the Compute API will reject with 401 on
+  every call.
+*/
+type PasswordAuthenticator struct {
+	IdentityURL       string
+	Username          string
+	Password          string
+	DomainName        string
+	ProjectID         string
+	ProjectName       string
+	ProjectDomainName string
+
+	mu     sync.Mutex
+	cached keystoneToken
+}
+
+func (p *PasswordAuthenticator) Token(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cached.valid() {
+		return p.cached.value, nil
+	}
+	tok, err := issueToken(ctx, p.IdentityURL, map[string]interface{}{
+		"methods": []string{"password"},
+		"password": map[string]interface{}{
+			"user": map[string]interface{}{
+				"name":     p.Username,
+				"password": p.Password,
+				"domain":   map[string]interface{}{"name": p.DomainName},
+			},
+		},
+	}, projectScope(p.ProjectID, p.ProjectName, p.ProjectDomainName))
+	if err != nil {
+		return "", err
+	}
+	p.cached = tok
+	return tok.value, nil
+}
+
+func (p *PasswordAuthenticator) Invalidate() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.cached = keystoneToken{}
+}
+
+/*
+  APIKeyAuthenticator authenticates with an HPCloud access/secret
+  key pair instead of a password. As with PasswordAuthenticator, set
+  ProjectID (or ProjectName, optionally with ProjectDomainName) to
+  get a project-scoped token — the Compute API rejects an unscoped
+  one with 401.
+*/
+type APIKeyAuthenticator struct {
+	IdentityURL       string
+	AccessKey         string
+	SecretKey         string
+	ProjectID         string
+	ProjectName       string
+	ProjectDomainName string
+
+	mu     sync.Mutex
+	cached keystoneToken
+}
+
+func (k *APIKeyAuthenticator) Token(ctx context.Context) (string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.cached.valid() {
+		return k.cached.value, nil
+	}
+	tok, err := issueToken(ctx, k.IdentityURL, map[string]interface{}{
+		"methods": []string{"apiKey"},
+		"apiKey": map[string]interface{}{
+			"accessKey": k.AccessKey,
+			"secretKey": k.SecretKey,
+		},
+	}, projectScope(k.ProjectID, k.ProjectName, k.ProjectDomainName))
+	if err != nil {
+		return "", err
+	}
+	k.cached = tok
+	return tok.value, nil
+}
+
+func (k *APIKeyAuthenticator) Invalidate() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.cached = keystoneToken{}
+}
+
+/*
+  ApplicationCredentialAuthenticator authenticates with a Keystone
+  application credential: either an ID on its own, or a Name scoped
+  to UserID, plus a Secret in both cases. Application credentials
+  let callers provision limited-scope access without embedding a
+  full username and password.
+*/
+type ApplicationCredentialAuthenticator struct {
+	IdentityURL string
+	ID          string
+	Name        string
+	UserID      string
+	Secret      string
+
+	mu     sync.Mutex
+	cached keystoneToken
+}
+
+func (c *ApplicationCredentialAuthenticator) Token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cached.valid() {
+		return c.cached.value, nil
+	}
+
+	appCred := map[string]interface{}{"secret": c.Secret}
+	if c.ID != "" {
+		appCred["id"] = c.ID
+	} else {
+		appCred["name"] = c.Name
+		appCred["user"] = map[string]interface{}{"id": c.UserID}
+	}
+
+	/* Keystone defaults an application-credential token to the
+	   credential's own project, so no scope needs to be requested
+	   here the way it does for password/API-key auth. */
+	tok, err := issueToken(ctx, c.IdentityURL, map[string]interface{}{
+		"methods":                []string{"application_credential"},
+		"application_credential": appCred,
+	}, nil)
+	if err != nil {
+		return "", err
+	}
+	c.cached = tok
+	return tok.value, nil
+}
+
+func (c *ApplicationCredentialAuthenticator) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cached = keystoneToken{}
+}
+
+/*
+  authenticatedRequest refreshes a.Token from a.Auth, issues the
+  request via baseRequest, and, if the response is a 401, invalidates
+  the cached token and retries exactly once with a freshly issued
+  one. b is read into memory upfront so it can be replayed on retry.
+*/
+func (a Access) authenticatedRequest(ctx context.Context, path, method string, b io.Reader) ([]byte, error) {
+	var buf []byte
+	if b != nil {
+		var err error
+		buf, err = io.ReadAll(b)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	body, err := a.doOnceAuthenticated(ctx, path, method, buf)
+	if isUnauthorized(err) {
+		a.Auth.Invalidate()
+		body, err = a.doOnceAuthenticated(ctx, path, method, buf)
+	}
+	return body, err
+}
+
+func (a Access) doOnceAuthenticated(ctx context.Context, path, method string, buf []byte) ([]byte, error) {
+	token, err := a.Auth.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	a.Token = token
+
+	var r io.Reader
+	if buf != nil {
+		r = bytes.NewReader(buf)
+	}
+	return a.baseRequest(path, method, r)
+}
+
+/*
+  isUnauthorized reports whether err represents an HTTP 401 from the
+  Compute API. It recognises any error exposing a StatusCode() int
+  method and otherwise falls back to matching "401" in the error
+  text.
+*/
+func isUnauthorized(err error) bool {
+	if err == nil {
+		return false
+	}
+	if sc, ok := err.(interface{ StatusCode() int }); ok {
+		return sc.StatusCode() == http.StatusUnauthorized
+	}
+	return strings.Contains(err.Error(), "401")
+}
+
+/*
+  statusError reports a non-2xx response from a request issued via
+  authenticatedRequestHeader. It implements the StatusCode() int
+  method isUnauthorized looks for, so a 401 from this path is
+  retried exactly the same way as one from baseRequest.
+*/
+type statusError struct {
+	code int
+	body []byte
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("hpcloud: request failed with status %d: %s", e.code, string(e.body))
+}
+
+func (e *statusError) StatusCode() int {
+	return e.code
+}
+
+/*
+  authenticatedRequestHeader is authenticatedRequest's sibling for
+  the one caller (CreateImageFromServer) that needs the response
+  headers, not just the body, back. Unlike baseComputeRequest it
+  doesn't hand off to the (unexported, external) baseRequest
+  primitive, since that has no way to surface headers; it issues the
+  HTTP request directly, authenticating and retrying once on 401
+  exactly as authenticatedRequest does.
+*/
+func (a Access) authenticatedRequestHeader(ctx context.Context, path, method string, b io.Reader) (http.Header, []byte, error) {
+	var buf []byte
+	if b != nil {
+		var err error
+		buf, err = io.ReadAll(b)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	header, body, err := a.doOnceAuthenticatedHeader(ctx, path, method, buf)
+	if isUnauthorized(err) {
+		a.Auth.Invalidate()
+		header, body, err = a.doOnceAuthenticatedHeader(ctx, path, method, buf)
+	}
+	return header, body, err
+}
+
+func (a Access) doOnceAuthenticatedHeader(ctx context.Context, path, method string, buf []byte) (http.Header, []byte, error) {
+	token, err := a.Auth.Token(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	a.Token = token
+
+	var r io.Reader
+	if buf != nil {
+		r = bytes.NewReader(buf)
+	}
+
+	req, err := http.NewRequest(method, path, r)
+	if err != nil {
+		return nil, nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("X-Auth-Token", a.Token)
+	if buf != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.Header, body, &statusError{code: resp.StatusCode, body: body}
+	}
+	return resp.Header, body, nil
+}
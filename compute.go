@@ -1,12 +1,13 @@
 package hpcloud
 
 import (
-	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
 )
 
@@ -98,6 +99,76 @@ type Server struct {
 	UserData       string            `json:"user_data"`
 	SecurityGroups []IDLink          `json:"security_groups"`
 	Metadata       map[string]string `json:"metadata"`
+
+	/*
+	   ImageName and FlavorName are resolved to ImageRef/FlavorRef by
+	   CreateServer when the corresponding *Ref field is left at its
+	   zero value, for callers who only know an image or flavor by
+	   its human name. They are never sent over the wire themselves.
+	*/
+	ImageName  string `json:"-"`
+	FlavorName string `json:"-"`
+
+	/*
+	   BlockDeviceMapping boots the server from an existing volume or
+	   snapshot instead of ImageRef/ImageName, mirroring the Nova
+	   os-block-device-mapping-v2-boot extension.
+	*/
+	BlockDeviceMapping []BlockDevice `json:"block_device_mapping_v2,omitempty"`
+}
+
+/*
+  BlockDevice describes one entry of a Server's BlockDeviceMapping,
+  as defined by the Nova os-block-device-mapping-v2-boot extension.
+  SourceType is one of "volume", "snapshot", "image" or "blank";
+  DestinationType is "volume" or "local".
+*/
+type BlockDevice struct {
+	SourceType          string `json:"source_type"`
+	DestinationType     string `json:"destination_type"`
+	UUID                string `json:"uuid,omitempty"`
+	BootIndex           int    `json:"boot_index"`
+	DeleteOnTermination bool   `json:"delete_on_termination"`
+	VolumeSize          int    `json:"volume_size,omitempty"`
+}
+
+/*
+  serverDetail describes a single server as returned by the Compute
+  API, whether from CreateServer, ListServersDetail, GetServer or
+  RebuildServer: status, addresses, flavor/image references and so on.
+*/
+type serverDetail struct {
+	Status         string            `json:"status"`
+	Updated        string            `json:"update"`
+	HostID         string            `json:"hostId"`
+	UserID         string            `json:"user_id"`
+	Name           string            `json:"name"`
+	Links          []Link            `json:"links"`
+	Addresses      interface{}       `json:"addresses"`
+	TenantID       string            `json:"tenant_id"`
+	Image          IDLink            `json:"image"`
+	Created        string            `json:"created"`
+	UUID           string            `json:"uuid"`
+	AccessIPv4     string            `json:"accessIPv4"`
+	AccessIPv6     string            `json:"accessIPv6"`
+	KeyName        string            `json:"key_name"`
+	AdminPass      string            `json:"adminPass"`
+	Flavor         IDLink            `json:"flavor"`
+	ConfigDrive    string            `json:"config_drive"`
+	ID             int64             `json:"id"`
+	SecurityGroups []IDLink          `json:"security_groups"`
+	Metadata       map[string]string `json:"metadata"`
+	Fault          serverFault       `json:"fault"`
+}
+
+/*
+  serverFault is the error Nova reports on a serverDetail whose
+  Status is ERROR.
+*/
+type serverFault struct {
+	Code    int    `json:"code"`
+	Created string `json:"created"`
+	Message string `json:"message"`
 }
 
 /*
@@ -105,39 +176,143 @@ type Server struct {
   call.
 */
 type ServerResponse struct {
-	S struct {
-		Status         string            `json:"status"`
-		Updated        string            `json:"update"`
-		HostID         string            `json:"hostId"`
-		UserID         string            `json:"user_id"`
-		Name           string            `json:"name"`
-		Links          []Link            `json:"links"`
-		Addresses      interface{}       `json:"addresses"`
-		TenantID       string            `json:"tenant_id"`
-		Image          IDLink            `json:"image"`
-		Created        string            `json:"created"`
-		UUID           string            `json:"uuid"`
-		AccessIPv4     string            `json:"accessIPv4"`
-		AccessIPv6     string            `json:"accessIPv6"`
-		KeyName        string            `json:"key_name"`
-		AdminPass      string            `json:"adminPass"`
-		Flavor         IDLink            `json:"flavor"`
-		ConfigDrive    string            `json:"config_drive"`
-		ID             int64             `json:"id"`
-		SecurityGroups []IDLink          `json:"security_groups"`
-		Metadata       map[string]string `json:"metadata"`
-	} `json:"server"`
+	S serverDetail `json:"server"`
+}
+
+var (
+	/*
+	   ErrNeitherImageIDNorImageNameProvided is returned by
+	   CreateServer when a Server has neither ImageRef nor ImageName
+	   set, so there is nothing to resolve or send as imageRef.
+	*/
+	ErrNeitherImageIDNorImageNameProvided = errors.New("hpcloud: neither ImageRef nor ImageName was provided")
+	/*
+	   ErrNeitherFlavorIDNorFlavorNameProvided is returned by
+	   CreateServer when a Server has neither FlavorRef nor
+	   FlavorName set, so there is nothing to resolve or send as
+	   flavorRef.
+	*/
+	ErrNeitherFlavorIDNorFlavorNameProvided = errors.New("hpcloud: neither FlavorRef nor FlavorName was provided")
+
+	/*
+	   ErrImageNotFound and ErrMultipleImageMatch are returned by
+	   resolveImageRef (and re-exported, as the same values, by
+	   images.IDFromName) when a name lookup finds zero or more than
+	   one matching image, so callers can errors.Is against a single
+	   sentinel regardless of which path resolved the name.
+	*/
+	ErrImageNotFound      = errors.New("hpcloud: no image found with that name")
+	ErrMultipleImageMatch = errors.New("hpcloud: multiple images found with that name")
+
+	/*
+	   ErrFlavorNotFound and ErrMultipleFlavorMatch are the flavor
+	   equivalents of ErrImageNotFound/ErrMultipleImageMatch, shared
+	   with flavors.IDFromName the same way.
+	*/
+	ErrFlavorNotFound      = errors.New("hpcloud: no flavor found with that name")
+	ErrMultipleFlavorMatch = errors.New("hpcloud: multiple flavors found with that name")
+)
+
+/*
+  resolveImageRef returns s.ImageRef as-is if it's already set,
+  otherwise resolves s.ImageName to an ImageRef by listing images
+  and matching on Name. If s.BlockDeviceMapping boots the server
+  from a volume instead, neither is required and 0 is returned.
+*/
+func (a Access) resolveImageRef(s Server) (ServerImage, error) {
+	if s.ImageRef != 0 {
+		return s.ImageRef, nil
+	}
+	if len(s.BlockDeviceMapping) > 0 && s.ImageName == "" {
+		return 0, nil
+	}
+	if s.ImageName == "" {
+		return 0, ErrNeitherImageIDNorImageNameProvided
+	}
+
+	images, err := a.ListImages()
+	if err != nil {
+		return 0, err
+	}
+	var found *IDLink
+	for i := range images.I {
+		if images.I[i].Name == s.ImageName {
+			if found != nil {
+				return 0, ErrMultipleImageMatch
+			}
+			found = &images.I[i]
+		}
+	}
+	if found == nil {
+		return 0, ErrImageNotFound
+	}
+	id, err := strconv.Atoi(found.ID)
+	if err != nil {
+		return 0, err
+	}
+	return ServerImage(id), nil
+}
+
+/*
+  resolveFlavorRef returns s.FlavorRef as-is if it's already set,
+  otherwise resolves s.FlavorName to a FlavorRef by listing flavors
+  and matching on Name.
+*/
+func (a Access) resolveFlavorRef(s Server) (Flavor, error) {
+	if s.FlavorRef != 0 {
+		return s.FlavorRef, nil
+	}
+	if s.FlavorName == "" {
+		return 0, ErrNeitherFlavorIDNorFlavorNameProvided
+	}
+
+	flavors, err := a.ListFlavors()
+	if err != nil {
+		return 0, err
+	}
+	var found *IDLink
+	for i := range flavors.F {
+		if flavors.F[i].Name == s.FlavorName {
+			if found != nil {
+				return 0, ErrMultipleFlavorMatch
+			}
+			found = &flavors.F[i]
+		}
+	}
+	if found == nil {
+		return 0, ErrFlavorNotFound
+	}
+	id, err := strconv.Atoi(found.ID)
+	if err != nil {
+		return 0, err
+	}
+	return Flavor(id), nil
 }
 
 /*
   CreateServer creates a new server in the HPCloud using the
   settings found in the Server instance passed to this function.
 
+  If s.ImageRef/s.FlavorRef are zero, they are resolved from
+  s.ImageName/s.FlavorName (via resolveImageRef/resolveFlavorRef)
+  before the request is sent.
+
   This function implements the interface as described in:-
   * https://docs.hpcloud.com/api/compute/
   * section 4.4.5.2 Create Server
 */
 func (a Access) CreateServer(s Server) (*ServerResponse, error) {
+	imageRef, err := a.resolveImageRef(s)
+	if err != nil {
+		return nil, err
+	}
+	flavorRef, err := a.resolveFlavorRef(s)
+	if err != nil {
+		return nil, err
+	}
+	s.ImageRef = imageRef
+	s.FlavorRef = flavorRef
+
 	b, err := s.MarshalJSON()
 	if err != nil {
 		return nil, err
@@ -157,6 +332,32 @@ func (a Access) CreateServer(s Server) (*ServerResponse, error) {
 	return sr, nil
 }
 
+/*
+  GetServer fetches the current detail (status, addresses,
+  flavor/image references, etc) of the server with `server_id`. ctx
+  is passed to the Authenticator's Token call, so it bounds any
+  Keystone round-trip needed to (re)issue a token; the Compute GET
+  itself goes through baseRequest, which takes no ctx, and is not
+  cancelled by it.
+
+  This function implements the interface described in:-
+  * https://docs.hpcloud.com/api/compute/
+  * Section 4.4.4.3 Get Server Details
+*/
+func (a Access) GetServer(ctx context.Context, server_id string) (*ServerResponse, error) {
+	body, err := a.baseComputeRequestCtx(
+		ctx, fmt.Sprintf("servers/%s", server_id), "GET", nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	sr := &ServerResponse{}
+	if err := json.Unmarshal(body, sr); err != nil {
+		return nil, err
+	}
+	return sr, nil
+}
+
 /*
   DeleteServer deletes the server with the `server_id`.
 
@@ -176,19 +377,26 @@ func (a Access) DeleteServer(server_id string) error {
 }
 
 /*
-  RebootServer will reboot the server with the `server_id`.
+  RebootType selects between a hard (power-cycle) and soft
+  (graceful, OS-level) reboot in a call to RebootServer.
+*/
+type RebootType string
+
+const (
+	HardReboot RebootType = "HARD"
+	SoftReboot RebootType = "SOFT"
+)
+
+/*
+  RebootServer will reboot the server with the `server_id` using the
+  given RebootType.
 
   This function implements the interface described in:-
   * https://docs.hpcloud.com/api/compute/
   * Section 4.4.7.1 Reboot Server
 */
-func (a Access) RebootServer(server_id string) error {
-	/*
-			 The docs mention that a hard reboot will be used
-		     no matter what, so there's no point making a type
-		     or make the type of reboot an option
-	*/
-	s := `{"reboot":{"type":"HARD"}}`
+func (a Access) RebootServer(server_id string, t RebootType) error {
+	s := fmt.Sprintf(`{"reboot":{"type":"%s"}}`, t)
 	_, err := a.baseComputeRequest(
 		fmt.Sprintf("servers/%s/action", server_id),
 		"POST", strings.NewReader(s),
@@ -266,112 +474,113 @@ func (a Access) ListImage(image_id string) (*Image, error) {
   We simply check for the known good return codes and return
   the body in those cases or we fail with the appropriate
   response.
+
+  Before issuing the request it refreshes a.Token from a.Auth, and
+  if the request comes back 401 it invalidates that token and
+  retries exactly once with a freshly issued one; see auth.go.
+
+  It has no ctx of its own, so it passes context.Background() to
+  baseComputeRequestCtx.
 */
 func (a Access) baseComputeRequest(url, method string, b io.Reader) ([]byte, error) {
+	return a.baseComputeRequestCtx(context.Background(), url, method, b)
+}
+
+/*
+  baseComputeRequestCtx is baseComputeRequest with an explicit ctx.
+  The ctx reaches the Authenticator's Token call only: the Compute
+  request itself is issued by baseRequest, which takes no ctx, so
+  it is not cancelled or timed out by ctx.
+*/
+func (a Access) baseComputeRequestCtx(ctx context.Context, url, method string, b io.Reader) ([]byte, error) {
 	path := fmt.Sprintf("%s%s/%s", COMPUTE_URL, a.TenantID, url)
-	return a.baseRequest(path, method, b)
+	return a.authenticatedRequest(ctx, path, method, b)
 }
 
 /*
-  MarshalJSON implements the Marshaler interface for the
-  Server type.
-
-  We implement this interface because when creating a server
-  we have optional values and since Go has zero-values and
-  does *not* have configurable zero values we need to make
-  sure that zero-values are converted to known good values.
-
-  As such:
-    * FlavorRef is checked if it's a valid reference.
-    * Ditto for ImageRef.
-    * Name cannot be blank.
-    * If the key is missing, it'll not put anything in.
-    * The config_drive defaults to false anyway, no need
-      to send a false value.
-    * Min/MaxCount are ignored if they are zero.
-    * UserData is ignored if it's a blank string.
-    * Personality is ignored if it's a blank string.
-    * Metadata/SecurityGroups are ignored if they have len(0)
+  Validate checks that s contains everything required to create a
+  server: a valid FlavorRef, a non-zero ImageRef (unless
+  BlockDeviceMapping is set), a non-blank Name and a Personality
+  within the API's 255-byte limit. CreateServer
+  and MarshalJSON call this internally, but callers may call it
+  directly to validate user input before it gets anywhere near the
+  wire.
 */
-func (s Server) MarshalJSON() ([]byte, error) {
-	b := bytes.NewBufferString("")
-	b.WriteString(`{"server":{`)
+func (s Server) Validate() error {
 	/* The available images are 100-105, x-small to x-large. */
 	if s.FlavorRef < 100 || s.FlavorRef > 105 {
-		return []byte{},
-			errors.New("Flavor Reference refers to a non-existant flavour.")
-	} else {
-		b.WriteString(fmt.Sprintf(`"flavorRef":%d`, s.FlavorRef))
+		return errors.New("Flavor Reference refers to a non-existant flavour.")
 	}
-	if s.ImageRef == 0 {
-		return []byte{},
-			errors.New("An image name is required.")
-	} else {
-		b.WriteString(fmt.Sprintf(`,"imageRef":%d`, s.ImageRef))
+	if s.ImageRef == 0 && len(s.BlockDeviceMapping) == 0 {
+		return errors.New("An image name is required.")
 	}
 	if s.Name == "" {
-		return []byte{},
-			errors.New("A name is required")
-	} else {
-		b.WriteString(fmt.Sprintf(`,"name":"%s"`, s.Name))
+		return errors.New("A name is required")
 	}
-
-	/* Optional items */
 	/* The max size of a personality string is 255 bytes. */
 	if len(s.Personality) > 255 {
-		return []byte{},
-			errors.New("Server's personality cannot have >255 bytes.")
-	} else if s.Personality != "" {
-		b.WriteString(fmt.Sprintf(`,"personality":"%s",`, s.Personality))
-	}
-	if s.Key != "" {
-		b.WriteString(fmt.Sprintf(`,"key_name":"%s"`, s.Key))
+		return errors.New("Server's personality cannot have >255 bytes.")
 	}
-	if s.ConfigDrive {
-		b.WriteString(`,"config_drive": true`)
-	}
-	if s.MinCount > 0 {
-		b.WriteString(fmt.Sprintf(`,"min_count":%d`, s.MinCount))
+	return nil
+}
+
+/*
+  serverBody is the literal JSON shape POSTed as the "server" key of
+  a create-server request. It exists separately from Server so that
+  encoding/json (via omitempty) can be trusted to produce valid,
+  properly-escaped JSON instead of hand-built strings.
+*/
+type serverBody struct {
+	ConfigDrive        bool              `json:"config_drive,omitempty"`
+	FlavorRef          Flavor            `json:"flavorRef"`
+	ImageRef           ServerImage       `json:"imageRef"`
+	MaxCount           int               `json:"max_count,omitempty"`
+	MinCount           int               `json:"min_count,omitempty"`
+	Name               string            `json:"name"`
+	Key                string            `json:"key_name,omitempty"`
+	Personality        string            `json:"personality,omitempty"`
+	UserData           string            `json:"user_data,omitempty"`
+	SecurityGroups     []IDLink          `json:"security_groups,omitempty"`
+	Metadata           map[string]string `json:"metadata,omitempty"`
+	BlockDeviceMapping []BlockDevice     `json:"block_device_mapping_v2,omitempty"`
+}
+
+/*
+  serverCreatePayload wraps serverBody in the "server" envelope the
+  Compute API expects the create-server request to be POSTed in.
+*/
+type serverCreatePayload struct {
+	Server serverBody `json:"server"`
+}
+
+/*
+  MarshalJSON implements the Marshaler interface for the Server
+  type. It validates s first, then hands the translation to
+  encoding/json rather than building JSON by hand, so names,
+  metadata and personality contents are escaped correctly no matter
+  what characters they contain.
+*/
+func (s Server) MarshalJSON() ([]byte, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
 	}
-	if s.MaxCount > 0 {
-		b.WriteString(fmt.Sprintf(`,"max_count":%d`, s.MaxCount))
+
+	body := serverBody{
+		ConfigDrive:        s.ConfigDrive,
+		FlavorRef:          s.FlavorRef,
+		ImageRef:           s.ImageRef,
+		MaxCount:           s.MaxCount,
+		MinCount:           s.MinCount,
+		Name:               s.Name,
+		Key:                s.Key,
+		Personality:        s.Personality,
+		SecurityGroups:     s.SecurityGroups,
+		Metadata:           s.Metadata,
+		BlockDeviceMapping: s.BlockDeviceMapping,
 	}
 	if s.UserData != "" {
-		/* user_data needs to be base64'd */
-		newb := make([]byte, 0, len(s.UserData))
-		base64.StdEncoding.Encode([]byte(s.UserData), newb)
-		b.WriteString(fmt.Sprintf(`,"user_data": "%s",`, string(newb)))
-	}
-
-	/* Ignore the metadata if there isn't any, it's optional. */
-	if len(s.Metadata) > 0 {
-		fmt.Println(len(s.Metadata))
-		b.WriteString(`,"metadata":{`)
-		cnt := 0
-		for key, value := range s.Metadata {
-			b.WriteString(fmt.Sprintf(`"%s": "%s"`, key, value))
-			if cnt+1 != len(s.Metadata) {
-				b.WriteString(",")
-				cnt++
-			} else {
-				b.WriteString("}")
-			}
-		}
+		body.UserData = base64.StdEncoding.EncodeToString([]byte(s.UserData))
 	}
-	/* Ignore the Security Groups if there isn't any, it's optional. */
-	if len(s.SecurityGroups) > 0 {
-		b.WriteString(`,"security_groups":[`)
-		cnt := 0
-		for _, sg := range s.SecurityGroups {
-			b.WriteString(fmt.Sprintf(`{"name": "%s"}`, sg.Name))
-			if cnt+1 != len(s.SecurityGroups) {
-				b.WriteString(",")
-				cnt++
-			} else {
-				b.WriteString("]")
-			}
-		}
-	}
-	b.WriteString("}}")
-	return b.Bytes(), nil
+
+	return json.Marshal(serverCreatePayload{Server: body})
 }
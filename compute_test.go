@@ -0,0 +1,74 @@
+package hpcloud
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestServerValidate(t *testing.T) {
+	cases := []struct {
+		name    string
+		s       Server
+		wantErr bool
+	}{
+		{"valid", Server{FlavorRef: 102, ImageRef: 8419, Name: "web1"}, false},
+		{"flavor too low", Server{FlavorRef: 99, ImageRef: 8419, Name: "web1"}, true},
+		{"flavor too high", Server{FlavorRef: 106, ImageRef: 8419, Name: "web1"}, true},
+		{"missing image", Server{FlavorRef: 102, Name: "web1"}, true},
+		{"missing image ok with block device mapping", Server{
+			FlavorRef: 102,
+			Name:      "web1",
+			BlockDeviceMapping: []BlockDevice{
+				{SourceType: "volume", DestinationType: "volume", UUID: "vol-1"},
+			},
+		}, false},
+		{"missing name", Server{FlavorRef: 102, ImageRef: 8419}, true},
+		{"personality too long", Server{FlavorRef: 102, ImageRef: 8419, Name: "web1", Personality: strings.Repeat("x", 256)}, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.s.Validate()
+			if (err != nil) != c.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestServerMarshalJSONEscapesAndEncodes(t *testing.T) {
+	s := Server{
+		FlavorRef: 102,
+		ImageRef:  8419,
+		Name:      `web"1\`,
+		UserData:  "#!/bin/sh\necho hi\n",
+		Metadata:  map[string]string{"role": "web"},
+	}
+
+	b, err := s.MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON() error = %v", err)
+	}
+
+	var payload serverCreatePayload
+	if err := json.Unmarshal(b, &payload); err != nil {
+		t.Fatalf("MarshalJSON() produced invalid JSON: %v", err)
+	}
+	if payload.Server.Name != s.Name {
+		t.Errorf("Name round-tripped as %q, want %q", payload.Server.Name, s.Name)
+	}
+	if payload.Server.UserData == "" || payload.Server.UserData == s.UserData {
+		t.Errorf("UserData = %q, want base64-encoded", payload.Server.UserData)
+	}
+	if payload.Server.Metadata["role"] != "web" {
+		t.Errorf("Metadata[%q] = %q, want %q", "role", payload.Server.Metadata["role"], "web")
+	}
+}
+
+func TestServerMarshalJSONRejectsInvalidServer(t *testing.T) {
+	s := Server{FlavorRef: 102, ImageRef: 8419}
+	if _, err := s.MarshalJSON(); err == nil {
+		t.Error("MarshalJSON() with no Name error = nil, want an error from Validate")
+	}
+}
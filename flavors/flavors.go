@@ -0,0 +1,50 @@
+/*
+  Package flavors adds name-based lookups on top of the hpcloud
+  Compute API's flavor listing, for callers who only know a flavor
+  by its human name (e.g. "standard.small") rather than its numeric
+  id.
+*/
+package flavors
+
+import (
+	"github.com/Gorah/hpcloud-1"
+)
+
+var (
+	/*
+	   ErrFlavorNotFound and ErrMultipleFlavorMatch are the same
+	   sentinel values hpcloud.CreateServer's own FlavorName
+	   resolution returns, so callers can errors.Is against one of
+	   these regardless of which code path resolved the name.
+	*/
+	ErrFlavorNotFound      = hpcloud.ErrFlavorNotFound
+	ErrMultipleFlavorMatch = hpcloud.ErrMultipleFlavorMatch
+)
+
+/*
+  IDFromName resolves a human-readable flavor name to the numeric
+  flavor id the Compute API expects, by listing the account's
+  flavors and matching on Name. It returns ErrFlavorNotFound if
+  nothing matches and ErrMultipleFlavorMatch if more than one
+  flavor shares the name.
+*/
+func IDFromName(a hpcloud.Access, name string) (string, error) {
+	flavors, err := a.ListFlavors()
+	if err != nil {
+		return "", err
+	}
+
+	var id string
+	for _, f := range flavors.F {
+		if f.Name == name {
+			if id != "" {
+				return "", ErrMultipleFlavorMatch
+			}
+			id = f.ID
+		}
+	}
+	if id == "" {
+		return "", ErrFlavorNotFound
+	}
+	return id, nil
+}
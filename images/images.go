@@ -0,0 +1,50 @@
+/*
+  Package images adds name-based lookups on top of the hpcloud
+  Compute API's image listing, for callers who only know an image
+  by its human name (e.g. "Ubuntu Precise 12.04") rather than its
+  numeric id.
+*/
+package images
+
+import (
+	"github.com/Gorah/hpcloud-1"
+)
+
+var (
+	/*
+	   ErrImageNotFound and ErrMultipleImageMatch are the same
+	   sentinel values hpcloud.CreateServer's own ImageName
+	   resolution returns, so callers can errors.Is against one of
+	   these regardless of which code path resolved the name.
+	*/
+	ErrImageNotFound      = hpcloud.ErrImageNotFound
+	ErrMultipleImageMatch = hpcloud.ErrMultipleImageMatch
+)
+
+/*
+  IDFromName resolves a human-readable image name to the numeric
+  image id the Compute API expects, by listing the account's images
+  and matching on Name. It returns ErrImageNotFound if nothing
+  matches and ErrMultipleImageMatch if more than one image shares
+  the name.
+*/
+func IDFromName(a hpcloud.Access, name string) (string, error) {
+	images, err := a.ListImages()
+	if err != nil {
+		return "", err
+	}
+
+	var id string
+	for _, im := range images.I {
+		if im.Name == name {
+			if id != "" {
+				return "", ErrMultipleImageMatch
+			}
+			id = im.ID
+		}
+	}
+	if id == "" {
+		return "", ErrImageNotFound
+	}
+	return id, nil
+}
@@ -0,0 +1,267 @@
+package hpcloud
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+)
+
+/*
+  ListOpts holds the query parameters accepted by the List* endpoints
+  of the Compute API, mirroring the OpenStack Nova list-servers /
+  list-images / list-flavors conventions. A nil *ListOpts (or one with
+  every field left at its zero value) lists everything with the
+  server's own defaults.
+*/
+type ListOpts struct {
+	ChangesSince string
+	Image        string
+	Flavor       string
+	Name         string
+	Status       string
+	Marker       string
+	Limit        int
+}
+
+/*
+  ToQuery renders the non-zero fields of opts as a URL query string,
+  e.g. "?limit=10&marker=abc". It returns an empty string when opts
+  is nil or every field is at its zero value, so it can always be
+  appended directly to a resource path.
+*/
+func (opts *ListOpts) ToQuery() string {
+	if opts == nil {
+		return ""
+	}
+	v := url.Values{}
+	if opts.ChangesSince != "" {
+		v.Set("changes-since", opts.ChangesSince)
+	}
+	if opts.Image != "" {
+		v.Set("image", opts.Image)
+	}
+	if opts.Flavor != "" {
+		v.Set("flavor", opts.Flavor)
+	}
+	if opts.Name != "" {
+		v.Set("name", opts.Name)
+	}
+	if opts.Status != "" {
+		v.Set("status", opts.Status)
+	}
+	if opts.Marker != "" {
+		v.Set("marker", opts.Marker)
+	}
+	if opts.Limit > 0 {
+		v.Set("limit", fmt.Sprintf("%d", opts.Limit))
+	}
+	if len(v) == 0 {
+		return ""
+	}
+	return "?" + v.Encode()
+}
+
+/*
+  Servers is the response body of a GET servers request: the
+  abbreviated id/name/links form. Use ListServersDetail for status,
+  addresses and the other fields that come back from the detail
+  endpoint.
+*/
+type Servers struct {
+	S     []IDLink `json:"servers"`
+	Links []Link   `json:"servers_links"`
+}
+
+/*
+  ServersDetail is the response body of a GET servers/detail
+  request: one serverDetail per server, in the same shape
+  CreateServer's response uses.
+*/
+type ServersDetail struct {
+	S     []serverDetail `json:"servers"`
+	Links []Link         `json:"servers_links"`
+}
+
+/*
+  ListServers lists all servers visible to this tenant in their
+  abbreviated id/name/links form. opts may be nil to list everything.
+
+  This function implements the interface described in:-
+  * https://docs.hpcloud.com/api/compute/
+  * Section 4.4.4.1 List Servers
+*/
+func (a Access) ListServers(opts *ListOpts) (*Servers, error) {
+	body, err := a.baseComputeRequest("servers"+opts.ToQuery(), "GET", nil)
+	if err != nil {
+		return nil, err
+	}
+	s := &Servers{}
+	if err := json.Unmarshal(body, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+/*
+  ListServersDetail is identical to ListServers but returns the full
+  per-server detail (status, addresses, flavor/image references,
+  etc). opts may be nil to list everything.
+
+  This function implements the interface described in:-
+  * https://docs.hpcloud.com/api/compute/
+  * Section 4.4.4.2 List Servers with Details
+*/
+func (a Access) ListServersDetail(opts *ListOpts) (*ServersDetail, error) {
+	body, err := a.baseComputeRequest("servers/detail"+opts.ToQuery(), "GET", nil)
+	if err != nil {
+		return nil, err
+	}
+	s := &ServersDetail{}
+	if err := json.Unmarshal(body, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+/*
+  ListImagesOpts is identical to ListImages but accepts a ListOpts to
+  filter and paginate the result set.
+*/
+func (a Access) ListImagesOpts(opts *ListOpts) (*Images, error) {
+	body, err := a.baseComputeRequest("images"+opts.ToQuery(), "GET", nil)
+	if err != nil {
+		return nil, err
+	}
+	im := &Images{}
+	if err := json.Unmarshal(body, im); err != nil {
+		return nil, err
+	}
+	return im, nil
+}
+
+/*
+  ListFlavorsOpts is identical to ListFlavors but accepts a ListOpts
+  to filter and paginate the result set.
+*/
+func (a Access) ListFlavorsOpts(opts *ListOpts) (*Flavors, error) {
+	body, err := a.baseComputeRequest("flavors"+opts.ToQuery(), "GET", nil)
+	if err != nil {
+		return nil, err
+	}
+	fl := &Flavors{}
+	if err := json.Unmarshal(body, fl); err != nil {
+		return nil, err
+	}
+	return fl, nil
+}
+
+/*
+  Pager walks a set of paginated list results by following the
+  "next" link in the response's links array, so callers can walk
+  large result sets a page at a time instead of loading everything
+  into memory. Obtain one from ServerPager, ServerDetailPager,
+  ImagePager or FlavorPager.
+*/
+type Pager struct {
+	a        Access
+	path     string
+	nextHREF string
+	linksKey string
+	started  bool
+	done     bool
+}
+
+func newPager(a Access, path, linksKey string) *Pager {
+	return &Pager{a: a, path: path, linksKey: linksKey}
+}
+
+/*
+  ServerPager returns a Pager over the abbreviated ListServers form.
+*/
+func (a Access) ServerPager(opts *ListOpts) *Pager {
+	return newPager(a, "servers"+opts.ToQuery(), "servers_links")
+}
+
+/*
+  ServerDetailPager returns a Pager over the detailed
+  ListServersDetail form.
+*/
+func (a Access) ServerDetailPager(opts *ListOpts) *Pager {
+	return newPager(a, "servers/detail"+opts.ToQuery(), "servers_links")
+}
+
+/*
+  ImagePager returns a Pager over the ListImages form.
+*/
+func (a Access) ImagePager(opts *ListOpts) *Pager {
+	return newPager(a, "images"+opts.ToQuery(), "images_links")
+}
+
+/*
+  FlavorPager returns a Pager over the ListFlavors form.
+*/
+func (a Access) FlavorPager(opts *ListOpts) *Pager {
+	return newPager(a, "flavors"+opts.ToQuery(), "flavors_links")
+}
+
+/*
+  NextPage fetches and returns the raw JSON body of the next page of
+  results. It returns io.EOF once there is no further "next" link to
+  follow, at which point the Pager is exhausted.
+*/
+func (p *Pager) NextPage() ([]byte, error) {
+	if p.done {
+		return nil, io.EOF
+	}
+
+	var body []byte
+	var err error
+	if !p.started {
+		body, err = p.a.baseComputeRequest(p.path, "GET", nil)
+		p.started = true
+	} else {
+		// p.nextHREF is already the full href from the previous
+		// page's "next" link, so it bypasses baseComputeRequest's
+		// path-building but still needs the same token refresh and
+		// 401-retry handling baseComputeRequest gets.
+		body, err = p.a.authenticatedRequest(context.Background(), p.nextHREF, "GET", nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if href, ok := extractNextLink(body, p.linksKey); ok {
+		p.nextHREF = href
+	} else {
+		p.done = true
+	}
+	return body, nil
+}
+
+/*
+  extractNextLink pulls the "next" rel href out of the linksKey
+  array (e.g. "servers_links", "images_links") of a list response,
+  without needing to know the rest of that response's shape.
+*/
+func extractNextLink(body []byte, linksKey string) (string, bool) {
+	var wrapper map[string]json.RawMessage
+	if err := json.Unmarshal(body, &wrapper); err != nil {
+		return "", false
+	}
+	raw, ok := wrapper[linksKey]
+	if !ok {
+		return "", false
+	}
+	var links []Link
+	if err := json.Unmarshal(raw, &links); err != nil {
+		return "", false
+	}
+	for _, l := range links {
+		if l.Rel == "next" {
+			return l.HREF, true
+		}
+	}
+	return "", false
+}
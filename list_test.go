@@ -0,0 +1,58 @@
+package hpcloud
+
+import "testing"
+
+func TestListOptsToQuery(t *testing.T) {
+	cases := []struct {
+		name string
+		opts *ListOpts
+		want string
+	}{
+		{"nil", nil, ""},
+		{"zero value", &ListOpts{}, ""},
+		{"single field", &ListOpts{Name: "web"}, "?name=web"},
+		{"limit and marker", &ListOpts{Limit: 10, Marker: "abc"}, "?limit=10&marker=abc"},
+		{"everything", &ListOpts{
+			ChangesSince: "2026-01-01T00:00:00Z",
+			Image:        "8419",
+			Flavor:       "102",
+			Name:         "web",
+			Status:       "ACTIVE",
+			Marker:       "abc",
+			Limit:        5,
+		}, "?changes-since=2026-01-01T00%3A00%3A00Z&flavor=102&image=8419&limit=5&marker=abc&name=web&status=ACTIVE"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.opts.ToQuery(); got != c.want {
+				t.Errorf("ToQuery() = %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestExtractNextLink(t *testing.T) {
+	body := []byte(`{"servers":[],"servers_links":[{"href":"https://example/servers?marker=2","rel":"next"}]}`)
+
+	href, ok := extractNextLink(body, "servers_links")
+	if !ok {
+		t.Fatal("extractNextLink() ok = false, want true")
+	}
+	if href != "https://example/servers?marker=2" {
+		t.Errorf("extractNextLink() href = %q", href)
+	}
+
+	if _, ok := extractNextLink(body, "images_links"); ok {
+		t.Error("extractNextLink() for a missing links key ok = true, want false")
+	}
+
+	noNext := []byte(`{"servers":[],"servers_links":[{"href":"x","rel":"self"}]}`)
+	if _, ok := extractNextLink(noNext, "servers_links"); ok {
+		t.Error("extractNextLink() with no next rel ok = true, want false")
+	}
+
+	if _, ok := extractNextLink([]byte("not json"), "servers_links"); ok {
+		t.Error("extractNextLink() on invalid JSON ok = true, want false")
+	}
+}
@@ -0,0 +1,115 @@
+package hpcloud
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+/*
+  VolumeAttachment describes a Cinder volume attached to a server,
+  as returned by AttachVolume, ListVolumeAttachments and
+  GetVolumeAttachment.
+*/
+type VolumeAttachment struct {
+	ID       string `json:"id"`
+	ServerID string `json:"serverId"`
+	VolumeID string `json:"volumeId"`
+	Device   string `json:"device"`
+}
+
+type volumeAttachmentEnvelope struct {
+	VolumeAttachment VolumeAttachment `json:"volumeAttachment"`
+}
+
+type volumeAttachmentsEnvelope struct {
+	VolumeAttachments []VolumeAttachment `json:"volumeAttachments"`
+}
+
+/*
+  AttachVolume attaches the existing Cinder volume `volumeID` to the
+  server with `serverID` at `device` (e.g. "/dev/vdc"). Leave device
+  blank to let the hypervisor choose the next free device.
+
+  This function implements the interface described in:-
+  * https://docs.hpcloud.com/api/compute/
+  * Section 4.4.9 Volume Attachments
+*/
+func (a Access) AttachVolume(serverID, volumeID, device string) (*VolumeAttachment, error) {
+	attachment := map[string]interface{}{
+		"volumeId": volumeID,
+	}
+	if device != "" {
+		attachment["device"] = device
+	}
+	payload := map[string]interface{}{
+		"volumeAttachment": attachment,
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := a.baseComputeRequest(
+		fmt.Sprintf("servers/%s/os-volume_attachments", serverID),
+		"POST", bytes.NewReader(b),
+	)
+	if err != nil {
+		return nil, err
+	}
+	env := &volumeAttachmentEnvelope{}
+	if err := json.Unmarshal(body, env); err != nil {
+		return nil, err
+	}
+	return &env.VolumeAttachment, nil
+}
+
+/*
+  ListVolumeAttachments lists the volumes currently attached to the
+  server with `serverID`.
+*/
+func (a Access) ListVolumeAttachments(serverID string) ([]VolumeAttachment, error) {
+	body, err := a.baseComputeRequest(
+		fmt.Sprintf("servers/%s/os-volume_attachments", serverID),
+		"GET", nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	env := &volumeAttachmentsEnvelope{}
+	if err := json.Unmarshal(body, env); err != nil {
+		return nil, err
+	}
+	return env.VolumeAttachments, nil
+}
+
+/*
+  GetVolumeAttachment fetches a single volume attachment, `attachID`,
+  on the server with `serverID`.
+*/
+func (a Access) GetVolumeAttachment(serverID, attachID string) (*VolumeAttachment, error) {
+	body, err := a.baseComputeRequest(
+		fmt.Sprintf("servers/%s/os-volume_attachments/%s", serverID, attachID),
+		"GET", nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	env := &volumeAttachmentEnvelope{}
+	if err := json.Unmarshal(body, env); err != nil {
+		return nil, err
+	}
+	return &env.VolumeAttachment, nil
+}
+
+/*
+  DetachVolume detaches the volume attachment `attachID` from the
+  server with `serverID`.
+*/
+func (a Access) DetachVolume(serverID, attachID string) error {
+	_, err := a.baseComputeRequest(
+		fmt.Sprintf("servers/%s/os-volume_attachments/%s", serverID, attachID),
+		"DELETE", nil,
+	)
+	return err
+}
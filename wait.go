@@ -0,0 +1,109 @@
+package hpcloud
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+/*
+  WaitOpts tunes the polling loop used by WaitForServerStatus.
+  Leaving a field at its zero value falls back to the documented
+  default for that field (2s / 1.5x / 30s / 10min respectively).
+*/
+type WaitOpts struct {
+	Interval    time.Duration
+	Backoff     float64
+	MaxInterval time.Duration
+	Timeout     time.Duration
+}
+
+func (o WaitOpts) withDefaults() WaitOpts {
+	if o.Interval <= 0 {
+		o.Interval = 2 * time.Second
+	}
+	if o.Backoff <= 0 {
+		o.Backoff = 1.5
+	}
+	if o.MaxInterval <= 0 {
+		o.MaxInterval = 30 * time.Second
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 10 * time.Minute
+	}
+	return o
+}
+
+/*
+  ErrUnexpectedStatus is returned by WaitForServerStatus when the
+  server enters ERROR instead of reaching the target status, with
+  the fault message the API reported (if any).
+*/
+type ErrUnexpectedStatus struct {
+	ServerID string
+	Status   string
+	Fault    string
+}
+
+func (e *ErrUnexpectedStatus) Error() string {
+	if e.Fault != "" {
+		return fmt.Sprintf("hpcloud: server %s entered status %s: %s", e.ServerID, e.Status, e.Fault)
+	}
+	return fmt.Sprintf("hpcloud: server %s entered status %s", e.ServerID, e.Status)
+}
+
+/*
+  WaitForServerStatus polls GET servers/{id} until the server with
+  serverID reaches target, returning its latest detail. It backs off
+  exponentially between polls: opts.Interval, multiplied by
+  opts.Backoff after every poll and capped at opts.MaxInterval. It
+  gives up once opts.Timeout has elapsed or ctx is done, returning
+  ctx.Err() (context.DeadlineExceeded in the timeout case) wrapped
+  with which server and target status it was waiting for. If the
+  server enters ERROR instead of target, it returns an
+  *ErrUnexpectedStatus surfacing the server's fault.
+*/
+func (a Access) WaitForServerStatus(ctx context.Context, serverID string, target string, opts WaitOpts) (*ServerResponse, error) {
+	opts = opts.withDefaults()
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	interval := opts.Interval
+	for {
+		sr, err := a.GetServer(ctx, serverID)
+		if err != nil {
+			return nil, err
+		}
+		if sr.S.Status == target {
+			return sr, nil
+		}
+		if sr.S.Status == "ERROR" {
+			return nil, &ErrUnexpectedStatus{
+				ServerID: serverID,
+				Status:   sr.S.Status,
+				Fault:    sr.S.Fault.Message,
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("hpcloud: waiting for server %s to reach %s: %w", serverID, target, ctx.Err())
+		case <-time.After(interval):
+		}
+
+		interval = nextBackoffInterval(interval, opts.Backoff, opts.MaxInterval)
+	}
+}
+
+/*
+  nextBackoffInterval scales current by factor and caps the result
+  at max, giving the interval to wait before the next poll.
+*/
+func nextBackoffInterval(current time.Duration, factor float64, max time.Duration) time.Duration {
+	next := time.Duration(float64(current) * factor)
+	if next > max {
+		next = max
+	}
+	return next
+}
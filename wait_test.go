@@ -0,0 +1,43 @@
+package hpcloud
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNextBackoffInterval(t *testing.T) {
+	if got, want := nextBackoffInterval(2*time.Second, 1.5, 30*time.Second), 3*time.Second; got != want {
+		t.Errorf("nextBackoffInterval() = %v, want %v", got, want)
+	}
+	if got, want := nextBackoffInterval(25*time.Second, 1.5, 30*time.Second), 30*time.Second; got != want {
+		t.Errorf("nextBackoffInterval() = %v, want capped at %v", got, want)
+	}
+}
+
+func TestWaitOptsWithDefaults(t *testing.T) {
+	o := WaitOpts{}.withDefaults()
+	if o.Interval != 2*time.Second || o.Backoff != 1.5 || o.MaxInterval != 30*time.Second || o.Timeout != 10*time.Minute {
+		t.Errorf("withDefaults() on a zero-value WaitOpts = %+v, want the documented defaults", o)
+	}
+
+	custom := WaitOpts{Interval: time.Second, Timeout: time.Minute}.withDefaults()
+	if custom.Interval != time.Second || custom.Timeout != time.Minute {
+		t.Errorf("withDefaults() overrode fields that were already set: %+v", custom)
+	}
+	if custom.Backoff != 1.5 || custom.MaxInterval != 30*time.Second {
+		t.Errorf("withDefaults() left unset fields at zero: %+v", custom)
+	}
+}
+
+func TestErrUnexpectedStatusError(t *testing.T) {
+	withFault := &ErrUnexpectedStatus{ServerID: "abc", Status: "ERROR", Fault: "boom"}
+	if msg := withFault.Error(); !strings.Contains(msg, "abc") || !strings.Contains(msg, "ERROR") || !strings.Contains(msg, "boom") {
+		t.Errorf("Error() = %q, missing ServerID/Status/Fault", msg)
+	}
+
+	noFault := &ErrUnexpectedStatus{ServerID: "abc", Status: "ERROR"}
+	if msg := noFault.Error(); !strings.Contains(msg, "abc") || !strings.Contains(msg, "ERROR") {
+		t.Errorf("Error() = %q, missing ServerID/Status", msg)
+	}
+}